@@ -0,0 +1,103 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymanager
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/keymanager/v1/secrets"
+	"k8s.io/cloud-provider-openstack/pkg/metrics"
+	"k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+const barbicanRefPrefix = "barbican://"
+
+// BarbicanConfig holds the dependencies the barbican backend needs. Client
+// is constructed by the caller the same way as CPO's other OpenStack service
+// clients (auth, region, endpoint type all come from the shared clientset),
+// rather than built inside this package.
+type BarbicanConfig struct {
+	Client     *gophercloud.ServiceClient
+	Encryption openstack.BlockStorageEncryptionOpts
+}
+
+type barbican struct {
+	client     *gophercloud.ServiceClient
+	encryption openstack.BlockStorageEncryptionOpts
+}
+
+func init() {
+	RegisterFactory("barbican", func(ctx context.Context, cfg Config) (KeyManager, error) {
+		if cfg.Barbican.Client == nil {
+			return nil, fmt.Errorf("keymanager: barbican provider requires a keymanager service client")
+		}
+		if err := openstack.ValidateEncryptionOpts(ctx, cfg.Barbican.Client, cfg.Barbican.Encryption); err != nil {
+			return nil, fmt.Errorf("keymanager: %w", err)
+		}
+		return &barbican{client: cfg.Barbican.Client, encryption: cfg.Barbican.Encryption}, nil
+	})
+}
+
+func (b *barbican) EnsureSecret(ctx context.Context, name string, tags map[string]string, payload []byte) (string, error) {
+	createOpts := b.encryption.ToCreateOpts(name, base64.StdEncoding.EncodeToString(payload), "application/octet-stream")
+	createOpts.PayloadContentEncoding = "base64"
+
+	secretRef, err := openstack.EnsureSecret(ctx, b.client, name, openstack.SecretMetadata(tags), createOpts)
+	if err != nil {
+		return "", err
+	}
+
+	secretID, err := openstack.ParseSecretID(secretRef)
+	if err != nil {
+		return "", err
+	}
+
+	return barbicanRefPrefix + secretID, nil
+}
+
+func (b *barbican) GetSecret(ctx context.Context, ref string) ([]byte, error) {
+	secretID, err := b.ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	mc := metrics.NewMetricContext("secret", "get_payload")
+	payload, err := secrets.GetPayload(ctx, b.client, secretID, secrets.GetPayloadOpts{}).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+func (b *barbican) DeleteByTag(ctx context.Context, tag string, value string) error {
+	_, err := openstack.DeleteSecrets(ctx, b.client, tag, value, openstack.DeleteOpts{})
+	return err
+}
+
+func (b *barbican) ParseRef(ref string) (string, error) {
+	id, ok := strings.CutPrefix(ref, barbicanRefPrefix)
+	if !ok || id == "" {
+		return "", fmt.Errorf("keymanager: %q is not a barbican ref", ref)
+	}
+
+	return id, nil
+}
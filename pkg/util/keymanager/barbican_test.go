@@ -0,0 +1,58 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymanager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+func TestNewKeyManagerBarbicanRejectsUnsupportedEncryptionOpts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       ts.URL + "/",
+	}
+
+	_, err := NewKeyManager(context.Background(), Config{
+		Provider: "barbican",
+		Barbican: BarbicanConfig{
+			Client:     client,
+			Encryption: openstack.BlockStorageEncryptionOpts{KeyAlgorithm: "blowfish"},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected NewKeyManager to reject an unsupported key algorithm at construction time")
+	}
+
+	_, err = NewKeyManager(context.Background(), Config{
+		Provider: "barbican",
+		Barbican: BarbicanConfig{Client: client},
+	})
+	if err != nil {
+		t.Fatalf("NewKeyManager with default encryption opts: %v", err)
+	}
+}
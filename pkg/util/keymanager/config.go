@@ -0,0 +1,70 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymanager
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"gopkg.in/gcfg.v1"
+)
+
+// cloudConfig mirrors just the slice of CPO's cloud-config file this package
+// cares about ([Global], [BlockStorage], ... live in CPO's own top-level
+// config type and are irrelevant here), so LoadConfig can parse a
+// [KeyManager] section straight out of the same file CPO already loads
+// everything else from.
+type cloudConfig struct {
+	KeyManager Config `gcfg:"KeyManager"`
+}
+
+// LoadConfig parses the [KeyManager] section out of a CPO cloud-config file.
+// Sections this package doesn't know about are ignored, so callers can pass
+// the whole cloud-config file rather than a KeyManager-only excerpt.
+func LoadConfig(r io.Reader) (Config, error) {
+	var cc cloudConfig
+	if err := gcfg.FatalOnly(gcfg.ReadInto(&cc, r)); err != nil {
+		return Config{}, fmt.Errorf("keymanager: parsing cloud-config: %w", err)
+	}
+	return cc.KeyManager, nil
+}
+
+// NewKeyManagerFromCloudConfig is the entry point CPO's cinder/manila
+// provisioning startup calls: it loads the [KeyManager] section from the
+// same cloud-config file used for everything else, and constructs the
+// selected backend. barbicanClient is the OpenStack keymanager service
+// client CPO already builds for every other Barbican call in this repo; it's
+// wired in as BarbicanConfig.Client whenever the barbican backend is
+// selected (including the default, unset provider) so existing
+// barbican-only deployments don't need any cloud-config changes to keep
+// working. Only the [KeyManager] section is read: BarbicanConfig.Encryption
+// is not filled from [BlockStorage.Encryption], so on this path the barbican
+// backend validates and uses the default encryption parameters.
+func NewKeyManagerFromCloudConfig(ctx context.Context, r io.Reader, barbicanClient *gophercloud.ServiceClient) (KeyManager, error) {
+	cfg, err := LoadConfig(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Provider == "" || cfg.Provider == "barbican" {
+		cfg.Barbican.Client = barbicanClient
+	}
+
+	return NewKeyManager(ctx, cfg)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymanager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+)
+
+func TestLoadConfig(t *testing.T) {
+	const cloudConfigINI = `
+[Global]
+auth-url = https://example.com/identity/v3
+
+[KeyManager]
+provider = vault
+`
+
+	cfg, err := LoadConfig(strings.NewReader(cloudConfigINI))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Provider != "vault" {
+		t.Errorf("Provider = %q, want %q", cfg.Provider, "vault")
+	}
+}
+
+func TestNewKeyManagerFromCloudConfig(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       ts.URL + "/",
+	}
+
+	km, err := NewKeyManagerFromCloudConfig(context.Background(), strings.NewReader(""), client)
+	if err != nil {
+		t.Fatalf("NewKeyManagerFromCloudConfig with no [KeyManager] section: %v", err)
+	}
+	if _, ok := km.(*barbican); !ok {
+		t.Errorf("expected the default provider to be barbican, got %T", km)
+	}
+
+	const explicitBarbicanINI = `
+[KeyManager]
+provider = barbican
+`
+	_, err = NewKeyManagerFromCloudConfig(context.Background(), strings.NewReader(explicitBarbicanINI), client)
+	if err != nil {
+		t.Fatalf("NewKeyManagerFromCloudConfig with explicit barbican provider: %v", err)
+	}
+
+	const vaultINI = `
+[KeyManager]
+provider = vault
+`
+	if _, err := NewKeyManagerFromCloudConfig(context.Background(), strings.NewReader(vaultINI), client); err == nil {
+		t.Error("expected an error selecting vault without a Vault client configured")
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/iterator"
+)
+
+const gcpsmRefPrefix = "gcpsm://"
+
+// GCPSMConfig holds the options needed to reach Google Secret Manager.
+// Client is constructed by the caller (picking up Application Default
+// Credentials, or a service account key referenced by
+// GOOGLE_APPLICATION_CREDENTIALS) so this package stays free of GCP auth
+// concerns, matching how BarbicanConfig takes an already-built ServiceClient.
+type GCPSMConfig struct {
+	Project string
+	Client  *secretmanager.Client
+}
+
+// secretManagerClient is the subset of the Google Secret Manager API gcpsm
+// depends on, flattened to plain arguments so tests can substitute a fake
+// without constructing gRPC request/response protos or iterators by hand.
+type secretManagerClient interface {
+	GetSecret(ctx context.Context, name string) (*secretmanagerpb.Secret, error)
+	CreateSecret(ctx context.Context, parent, secretID string, secret *secretmanagerpb.Secret) (*secretmanagerpb.Secret, error)
+	AddSecretVersion(ctx context.Context, parent string, payload []byte) (*secretmanagerpb.SecretVersion, error)
+	AccessSecretVersion(ctx context.Context, name string) ([]byte, error)
+	ListSecretsByLabel(ctx context.Context, tag, value string) ([]*secretmanagerpb.Secret, error)
+	DeleteSecret(ctx context.Context, name string) error
+}
+
+// realSecretManagerClient adapts *secretmanager.Client to secretManagerClient.
+type realSecretManagerClient struct {
+	client  *secretmanager.Client
+	project string
+}
+
+func (r *realSecretManagerClient) GetSecret(ctx context.Context, name string) (*secretmanagerpb.Secret, error) {
+	return r.client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{Name: name})
+}
+
+func (r *realSecretManagerClient) CreateSecret(ctx context.Context, parent, secretID string, secret *secretmanagerpb.Secret) (*secretmanagerpb.Secret, error) {
+	return r.client.CreateSecret(ctx, &secretmanagerpb.CreateSecretRequest{
+		Parent:   parent,
+		SecretId: secretID,
+		Secret:   secret,
+	})
+}
+
+func (r *realSecretManagerClient) AddSecretVersion(ctx context.Context, parent string, payload []byte) (*secretmanagerpb.SecretVersion, error) {
+	return r.client.AddSecretVersion(ctx, &secretmanagerpb.AddSecretVersionRequest{
+		Parent:  parent,
+		Payload: &secretmanagerpb.SecretPayload{Data: payload},
+	})
+}
+
+func (r *realSecretManagerClient) AccessSecretVersion(ctx context.Context, name string) ([]byte, error) {
+	resp, err := r.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Payload.Data, nil
+}
+
+func (r *realSecretManagerClient) ListSecretsByLabel(ctx context.Context, tag, value string) ([]*secretmanagerpb.Secret, error) {
+	it := r.client.ListSecrets(ctx, &secretmanagerpb.ListSecretsRequest{
+		Parent: fmt.Sprintf("projects/%s", r.project),
+		Filter: fmt.Sprintf("labels.%s=%s", tag, value),
+	})
+
+	var matched []*secretmanagerpb.Secret
+	for {
+		secret, err := it.Next()
+		if err == iterator.Done {
+			return matched, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		matched = append(matched, secret)
+	}
+}
+
+func (r *realSecretManagerClient) DeleteSecret(ctx context.Context, name string) error {
+	return r.client.DeleteSecret(ctx, &secretmanagerpb.DeleteSecretRequest{Name: name})
+}
+
+type gcpsm struct {
+	project string
+	client  secretManagerClient
+}
+
+func init() {
+	RegisterFactory("gcpsm", func(_ context.Context, cfg Config) (KeyManager, error) {
+		if cfg.GCPSM.Client == nil {
+			return nil, fmt.Errorf("keymanager: gcpsm provider requires a Secret Manager client")
+		}
+		if cfg.GCPSM.Project == "" {
+			return nil, fmt.Errorf("keymanager: gcpsm provider requires a project")
+		}
+		return &gcpsm{
+			project: cfg.GCPSM.Project,
+			client:  &realSecretManagerClient{client: cfg.GCPSM.Client, project: cfg.GCPSM.Project},
+		}, nil
+	})
+}
+
+func (g *gcpsm) secretName(name string) string {
+	return fmt.Sprintf("projects/%s/secrets/%s", g.project, name)
+}
+
+// EnsureSecret creates the secret and its first version if the secret
+// doesn't already exist. If it does, EnsureSecret returns a ref to its
+// latest version rather than minting a new one, so repeated calls (e.g. from
+// a reconcile loop) don't accumulate an unbounded number of secret versions.
+func (g *gcpsm) EnsureSecret(ctx context.Context, name string, tags map[string]string, payload []byte) (string, error) {
+	secretPath := g.secretName(name)
+
+	secret, err := g.client.GetSecret(ctx, secretPath)
+	if err != nil {
+		secret, err = g.client.CreateSecret(ctx, fmt.Sprintf("projects/%s", g.project), name, &secretmanagerpb.Secret{
+			Labels: tags,
+			Replication: &secretmanagerpb.Replication{
+				Replication: &secretmanagerpb.Replication_Automatic_{
+					Automatic: &secretmanagerpb.Replication_Automatic{},
+				},
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("keymanager: creating gcpsm secret %s: %w", name, err)
+		}
+
+		version, err := g.client.AddSecretVersion(ctx, secret.Name, payload)
+		if err != nil {
+			return "", fmt.Errorf("keymanager: adding gcpsm secret version for %s: %w", name, err)
+		}
+
+		return gcpsmRefPrefix + version.Name, nil
+	}
+
+	return gcpsmRefPrefix + secret.Name + "/versions/latest", nil
+}
+
+func (g *gcpsm) GetSecret(ctx context.Context, ref string) ([]byte, error) {
+	versionName, err := g.ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := g.client.AccessSecretVersion(ctx, versionName)
+	if err != nil {
+		return nil, fmt.Errorf("keymanager: accessing gcpsm secret version %s: %w", versionName, err)
+	}
+
+	return payload, nil
+}
+
+func (g *gcpsm) DeleteByTag(ctx context.Context, tag string, value string) error {
+	matched, err := g.client.ListSecretsByLabel(ctx, tag, value)
+	if err != nil {
+		return fmt.Errorf("keymanager: listing gcpsm secrets tagged %s=%s: %w", tag, value, err)
+	}
+
+	for _, secret := range matched {
+		if err := g.client.DeleteSecret(ctx, secret.Name); err != nil {
+			return fmt.Errorf("keymanager: deleting gcpsm secret %s: %w", secret.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (g *gcpsm) ParseRef(ref string) (string, error) {
+	name, ok := strings.CutPrefix(ref, gcpsmRefPrefix)
+	if !ok || !strings.HasPrefix(name, "projects/") {
+		return "", fmt.Errorf("keymanager: %q is not a gcpsm ref", ref)
+	}
+
+	return name, nil
+}
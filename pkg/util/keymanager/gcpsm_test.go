@@ -0,0 +1,133 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymanager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+)
+
+type fakeSecretManagerClient struct {
+	secrets          map[string]*secretmanagerpb.Secret
+	versionsAdded    int
+	addSecretVersion func(parent string, payload []byte) (*secretmanagerpb.SecretVersion, error)
+}
+
+func (f *fakeSecretManagerClient) GetSecret(_ context.Context, name string) (*secretmanagerpb.Secret, error) {
+	secret, ok := f.secrets[name]
+	if !ok {
+		return nil, fmt.Errorf("not found: %s", name)
+	}
+	return secret, nil
+}
+
+func (f *fakeSecretManagerClient) CreateSecret(_ context.Context, _, secretID string, secret *secretmanagerpb.Secret) (*secretmanagerpb.Secret, error) {
+	name := fmt.Sprintf("projects/p/secrets/%s", secretID)
+	secret.Name = name
+	f.secrets[name] = secret
+	return secret, nil
+}
+
+func (f *fakeSecretManagerClient) AddSecretVersion(_ context.Context, parent string, payload []byte) (*secretmanagerpb.SecretVersion, error) {
+	f.versionsAdded++
+	if f.addSecretVersion != nil {
+		return f.addSecretVersion(parent, payload)
+	}
+	return &secretmanagerpb.SecretVersion{Name: parent + "/versions/1"}, nil
+}
+
+func (f *fakeSecretManagerClient) AccessSecretVersion(_ context.Context, name string) ([]byte, error) {
+	return []byte("payload:" + name), nil
+}
+
+func (f *fakeSecretManagerClient) ListSecretsByLabel(_ context.Context, tag, value string) ([]*secretmanagerpb.Secret, error) {
+	var matched []*secretmanagerpb.Secret
+	for _, secret := range f.secrets {
+		if secret.Labels[tag] == value {
+			matched = append(matched, secret)
+		}
+	}
+	return matched, nil
+}
+
+func (f *fakeSecretManagerClient) DeleteSecret(_ context.Context, name string) error {
+	delete(f.secrets, name)
+	return nil
+}
+
+func TestGCPSMEnsureSecretIsIdempotent(t *testing.T) {
+	fake := &fakeSecretManagerClient{secrets: map[string]*secretmanagerpb.Secret{}}
+	g := &gcpsm{project: "p", client: fake}
+
+	ref1, err := g.EnsureSecret(context.Background(), "my-secret", map[string]string{"k8s-pv-name": "pvc-1"}, []byte("payload"))
+	if err != nil {
+		t.Fatalf("first EnsureSecret: %v", err)
+	}
+
+	ref2, err := g.EnsureSecret(context.Background(), "my-secret", map[string]string{"k8s-pv-name": "pvc-1"}, []byte("payload"))
+	if err != nil {
+		t.Fatalf("second EnsureSecret: %v", err)
+	}
+
+	if fake.versionsAdded != 1 {
+		t.Errorf("expected exactly one AddSecretVersion call across two EnsureSecret calls for the same secret, got %d", fake.versionsAdded)
+	}
+	if ref1 == ref2 {
+		t.Errorf("expected the second call to return the latest-version alias distinct from the first concrete version ref, got %q twice", ref1)
+	}
+	if got := "gcpsm://projects/p/secrets/my-secret/versions/latest"; ref2 != got {
+		t.Errorf("second EnsureSecret ref = %q, want %q", ref2, got)
+	}
+}
+
+func TestGCPSMDeleteByTag(t *testing.T) {
+	fake := &fakeSecretManagerClient{secrets: map[string]*secretmanagerpb.Secret{
+		"projects/p/secrets/a": {Name: "projects/p/secrets/a", Labels: map[string]string{"csi-volume-id": "vol-1"}},
+		"projects/p/secrets/b": {Name: "projects/p/secrets/b", Labels: map[string]string{"csi-volume-id": "vol-2"}},
+	}}
+	g := &gcpsm{project: "p", client: fake}
+
+	if err := g.DeleteByTag(context.Background(), "csi-volume-id", "vol-1"); err != nil {
+		t.Fatalf("DeleteByTag: %v", err)
+	}
+
+	if _, ok := fake.secrets["projects/p/secrets/a"]; ok {
+		t.Error("secret a should have been deleted")
+	}
+	if _, ok := fake.secrets["projects/p/secrets/b"]; !ok {
+		t.Error("secret b should not have been deleted")
+	}
+}
+
+func TestGCPSMParseRef(t *testing.T) {
+	g := &gcpsm{}
+
+	if _, err := g.ParseRef("barbican://abc"); err == nil {
+		t.Error("expected error parsing a non-gcpsm ref")
+	}
+
+	name, err := g.ParseRef("gcpsm://projects/p/secrets/s/versions/1")
+	if err != nil {
+		t.Fatalf("ParseRef: %v", err)
+	}
+	if want := "projects/p/secrets/s/versions/1"; name != want {
+		t.Errorf("ParseRef = %q, want %q", name, want)
+	}
+}
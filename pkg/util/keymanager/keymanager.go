@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package keymanager provides a pluggable interface for the secret backend
+// behind CPO's cinder/manila encryption-at-rest flow, so clusters that don't
+// run Barbican (e.g. GKE-on-OpenStack, or clusters standardized on Vault)
+// can still use it.
+package keymanager
+
+import (
+	"context"
+	"fmt"
+)
+
+// KeyManager is implemented by each supported secret backend. Refs returned
+// by EnsureSecret are opaque, provider-scoped URLs (e.g.
+// "barbican://<secret-id>", "gcpsm://projects/x/secrets/y/versions/z") that
+// round-trip through ParseRef/GetSecret without callers needing to know
+// which backend produced them.
+type KeyManager interface {
+	// EnsureSecret creates a secret carrying payload and tags unless the
+	// backend already holds a matching one, and returns its ref either way.
+	// barbican matches an existing secret by tags; gcpsm and vault match by
+	// name.
+	EnsureSecret(ctx context.Context, name string, tags map[string]string, payload []byte) (ref string, err error)
+
+	// GetSecret returns the raw payload bytes stored under ref.
+	GetSecret(ctx context.Context, ref string) ([]byte, error)
+
+	// DeleteByTag deletes every secret tagged tag=value.
+	DeleteByTag(ctx context.Context, tag string, value string) error
+
+	// ParseRef validates that ref belongs to this backend and returns the
+	// backend-local identifier encoded within it.
+	ParseRef(ref string) (id string, err error)
+}
+
+// Config configures which KeyManager backend NewKeyManager constructs and
+// how. Provider is read from the [KeyManager] section of CPO's cloud-config;
+// the per-backend fields (clients, project, mount path, encryption options)
+// are set by the caller, not parsed from the file.
+type Config struct {
+	// Provider selects the backend: "barbican" (default), "gcpsm" or "vault".
+	Provider string `gcfg:"provider"`
+
+	Barbican BarbicanConfig `gcfg:"barbican"`
+	GCPSM    GCPSMConfig    `gcfg:"gcpsm"`
+	Vault    VaultConfig    `gcfg:"vault"`
+}
+
+// Factory constructs a KeyManager from Config. Backends register themselves
+// under a provider name via RegisterFactory from an init() function. ctx
+// bounds any boot-time validation the factory performs, such as barbican's
+// check that the target deployment supports the configured encryption
+// parameters.
+type Factory func(ctx context.Context, cfg Config) (KeyManager, error)
+
+var factories = map[string]Factory{}
+
+// RegisterFactory makes a backend available under name for NewKeyManager to
+// construct. It panics on duplicate registration since that can only happen
+// from a programming error at init time, not from user input.
+func RegisterFactory(name string, f Factory) {
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("keymanager: backend %q registered twice", name))
+	}
+	factories[name] = f
+}
+
+// NewKeyManager constructs the backend selected by cfg.Provider, defaulting
+// to "barbican" so cloud-configs written before this option existed keep
+// working unmodified.
+func NewKeyManager(ctx context.Context, cfg Config) (KeyManager, error) {
+	provider := cfg.Provider
+	if provider == "" {
+		provider = "barbican"
+	}
+
+	factory, ok := factories[provider]
+	if !ok {
+		return nil, fmt.Errorf("keymanager: unknown provider %q", provider)
+	}
+
+	return factory(ctx, cfg)
+}
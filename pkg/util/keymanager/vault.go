@@ -0,0 +1,225 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+const vaultRefPrefix = "vault://"
+
+// VaultConfig holds the options needed to reach a HashiCorp Vault KV v2
+// mount. Client is constructed by the caller (address/token/TLS all come
+// from Vault's own env-driven config), matching how the other backends take
+// an already-authenticated client rather than owning auth themselves.
+type VaultConfig struct {
+	// MountPath is the KV v2 secrets engine mount, e.g. "secret".
+	MountPath string
+	Client    *vaultapi.Client
+}
+
+// vaultBackend is the subset of Vault's KV v2 API vault depends on, flattened
+// to plain maps so tests can substitute a fake instead of talking to a real
+// Vault server, the same role secretManagerClient plays for gcpsm.
+type vaultBackend interface {
+	// Get returns the raw KV v2 data map stored under name.
+	Get(ctx context.Context, name string) (map[string]interface{}, error)
+	// Put writes data as the (only) payload fields for name.
+	Put(ctx context.Context, name string, data map[string]interface{}) error
+	// GetMetadata returns the custom_metadata map stored for name.
+	GetMetadata(ctx context.Context, name string) (map[string]interface{}, error)
+	// PutMetadata replaces the custom_metadata map stored for name.
+	PutMetadata(ctx context.Context, name string, metadata map[string]interface{}) error
+	// DeleteMetadata deletes name along with all of its versions.
+	DeleteMetadata(ctx context.Context, name string) error
+	// ListNames lists every secret name under the mount.
+	ListNames(ctx context.Context) ([]string, error)
+}
+
+// realVaultBackend adapts a *vaultapi.Client mount to vaultBackend.
+type realVaultBackend struct {
+	client    *vaultapi.Client
+	mountPath string
+}
+
+func (r *realVaultBackend) kv() *vaultapi.KVv2 {
+	return r.client.KVv2(r.mountPath)
+}
+
+func (r *realVaultBackend) Get(ctx context.Context, name string) (map[string]interface{}, error) {
+	secret, err := r.kv().Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
+func (r *realVaultBackend) Put(ctx context.Context, name string, data map[string]interface{}) error {
+	_, err := r.kv().Put(ctx, name, data)
+	return err
+}
+
+func (r *realVaultBackend) GetMetadata(ctx context.Context, name string) (map[string]interface{}, error) {
+	meta, err := r.kv().GetMetadata(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return meta.CustomMetadata, nil
+}
+
+func (r *realVaultBackend) PutMetadata(ctx context.Context, name string, metadata map[string]interface{}) error {
+	return r.kv().PutMetadata(ctx, name, vaultapi.KVMetadataPutInput{CustomMetadata: metadata})
+}
+
+func (r *realVaultBackend) DeleteMetadata(ctx context.Context, name string) error {
+	return r.kv().DeleteMetadata(ctx, name)
+}
+
+func (r *realVaultBackend) ListNames(ctx context.Context) ([]string, error) {
+	names, err := r.client.Logical().ListWithContext(ctx, r.mountPath+"/metadata")
+	if err != nil {
+		return nil, err
+	}
+	if names == nil || names.Data["keys"] == nil {
+		return nil, nil
+	}
+
+	keys, ok := names.Data["keys"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("keymanager: unexpected vault list response for %s/metadata", r.mountPath)
+	}
+
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if name, ok := k.(string); ok {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
+type vault struct {
+	mountPath string
+	backend   vaultBackend
+}
+
+func init() {
+	RegisterFactory("vault", func(_ context.Context, cfg Config) (KeyManager, error) {
+		if cfg.Vault.Client == nil {
+			return nil, fmt.Errorf("keymanager: vault provider requires a Vault client")
+		}
+		mountPath := cfg.Vault.MountPath
+		if mountPath == "" {
+			mountPath = "secret"
+		}
+		return &vault{
+			mountPath: mountPath,
+			backend:   &realVaultBackend{client: cfg.Vault.Client, mountPath: mountPath},
+		}, nil
+	})
+}
+
+// EnsureSecret writes the secret and its custom metadata only if a secret by
+// this name doesn't already exist, so repeated calls (e.g. from a reconcile
+// loop) don't keep pushing new KV versions.
+func (v *vault) EnsureSecret(ctx context.Context, name string, tags map[string]string, payload []byte) (string, error) {
+	if _, err := v.backend.Get(ctx, name); err == nil {
+		return vaultRefPrefix + v.mountPath + "/" + name, nil
+	}
+
+	data := map[string]interface{}{"payload": string(payload)}
+	if err := v.backend.Put(ctx, name, data); err != nil {
+		return "", fmt.Errorf("keymanager: writing vault secret %s: %w", name, err)
+	}
+
+	if len(tags) > 0 {
+		metadata := make(map[string]interface{}, len(tags))
+		for k, val := range tags {
+			metadata[k] = val
+		}
+		if err := v.backend.PutMetadata(ctx, name, metadata); err != nil {
+			return "", fmt.Errorf("keymanager: writing vault secret metadata for %s: %w", name, err)
+		}
+	}
+
+	return vaultRefPrefix + v.mountPath + "/" + name, nil
+}
+
+func (v *vault) GetSecret(ctx context.Context, ref string) ([]byte, error) {
+	name, err := v.ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := v.backend.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("keymanager: reading vault secret %s: %w", name, err)
+	}
+
+	payload, ok := data["payload"].(string)
+	if !ok {
+		return nil, fmt.Errorf("keymanager: vault secret %s has no payload field", name)
+	}
+
+	return []byte(payload), nil
+}
+
+// DeleteByTag deletes every secret under this mount whose custom metadata has
+// tag set to value. Vault's KV v2 API has no server-side metadata filter, so
+// this lists every secret path under the mount and checks metadata one at a
+// time; acceptable for the PV-encryption-key volumes CPO manages, but not
+// something to run against a mount shared with unrelated secrets.
+func (v *vault) DeleteByTag(ctx context.Context, tag string, value string) error {
+	names, err := v.backend.ListNames(ctx)
+	if err != nil {
+		return fmt.Errorf("keymanager: listing vault secrets: %w", err)
+	}
+
+	for _, name := range names {
+		metadata, err := v.backend.GetMetadata(ctx, name)
+		if err != nil {
+			return fmt.Errorf("keymanager: reading vault secret metadata for %s: %w", name, err)
+		}
+		if fmt.Sprintf("%v", metadata[tag]) != value {
+			continue
+		}
+
+		if err := v.backend.DeleteMetadata(ctx, name); err != nil {
+			return fmt.Errorf("keymanager: deleting vault secret %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (v *vault) ParseRef(ref string) (string, error) {
+	rest, ok := strings.CutPrefix(ref, vaultRefPrefix)
+	if !ok {
+		return "", fmt.Errorf("keymanager: %q is not a vault ref", ref)
+	}
+
+	name, ok := strings.CutPrefix(rest, v.mountPath+"/")
+	if !ok || name == "" {
+		return "", fmt.Errorf("keymanager: %q is not a ref on mount %q", ref, v.mountPath)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keymanager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// fakeVaultBackend is an in-memory vaultBackend for tests, avoiding a real
+// Vault server the same way fakeSecretManagerClient does for gcpsm.
+type fakeVaultBackend struct {
+	data     map[string]map[string]interface{}
+	metadata map[string]map[string]interface{}
+	putCalls int
+	deleted  []string
+}
+
+func newFakeVaultBackend() *fakeVaultBackend {
+	return &fakeVaultBackend{
+		data:     map[string]map[string]interface{}{},
+		metadata: map[string]map[string]interface{}{},
+	}
+}
+
+func (f *fakeVaultBackend) Get(_ context.Context, name string) (map[string]interface{}, error) {
+	data, ok := f.data[name]
+	if !ok {
+		return nil, fmt.Errorf("no secret named %s", name)
+	}
+	return data, nil
+}
+
+func (f *fakeVaultBackend) Put(_ context.Context, name string, data map[string]interface{}) error {
+	f.putCalls++
+	f.data[name] = data
+	return nil
+}
+
+func (f *fakeVaultBackend) GetMetadata(_ context.Context, name string) (map[string]interface{}, error) {
+	return f.metadata[name], nil
+}
+
+func (f *fakeVaultBackend) PutMetadata(_ context.Context, name string, metadata map[string]interface{}) error {
+	f.metadata[name] = metadata
+	return nil
+}
+
+func (f *fakeVaultBackend) DeleteMetadata(_ context.Context, name string) error {
+	delete(f.data, name)
+	delete(f.metadata, name)
+	f.deleted = append(f.deleted, name)
+	return nil
+}
+
+func (f *fakeVaultBackend) ListNames(_ context.Context) ([]string, error) {
+	names := make([]string, 0, len(f.data))
+	for name := range f.data {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func TestVaultEnsureSecretIsIdempotent(t *testing.T) {
+	backend := newFakeVaultBackend()
+	v := &vault{mountPath: "secret", backend: backend}
+
+	ref1, err := v.EnsureSecret(context.Background(), "my-secret", map[string]string{"k8s-pv-name": "pvc-1"}, []byte("payload"))
+	if err != nil {
+		t.Fatalf("first EnsureSecret: %v", err)
+	}
+
+	ref2, err := v.EnsureSecret(context.Background(), "my-secret", map[string]string{"k8s-pv-name": "pvc-1"}, []byte("payload"))
+	if err != nil {
+		t.Fatalf("second EnsureSecret: %v", err)
+	}
+
+	if ref1 != ref2 {
+		t.Errorf("refs differ across idempotent calls: %q vs %q", ref1, ref2)
+	}
+	if backend.putCalls != 1 {
+		t.Errorf("Put called %d times, want 1", backend.putCalls)
+	}
+
+	payload, err := v.GetSecret(context.Background(), ref1)
+	if err != nil {
+		t.Fatalf("GetSecret: %v", err)
+	}
+	if string(payload) != "payload" {
+		t.Errorf("payload = %q, want %q", payload, "payload")
+	}
+}
+
+func TestVaultDeleteByTag(t *testing.T) {
+	backend := newFakeVaultBackend()
+	v := &vault{mountPath: "secret", backend: backend}
+
+	if _, err := v.EnsureSecret(context.Background(), "keep", map[string]string{"csi-volume-id": "other"}, []byte("a")); err != nil {
+		t.Fatalf("EnsureSecret(keep): %v", err)
+	}
+	if _, err := v.EnsureSecret(context.Background(), "drop", map[string]string{"csi-volume-id": "target"}, []byte("b")); err != nil {
+		t.Fatalf("EnsureSecret(drop): %v", err)
+	}
+
+	if err := v.DeleteByTag(context.Background(), "csi-volume-id", "target"); err != nil {
+		t.Fatalf("DeleteByTag: %v", err)
+	}
+
+	if _, err := backend.Get(context.Background(), "drop"); err == nil {
+		t.Error("expected the tagged secret to be deleted")
+	}
+	if _, err := backend.Get(context.Background(), "keep"); err != nil {
+		t.Errorf("secret with a different tag value was deleted: %v", err)
+	}
+}
+
+func TestVaultParseRef(t *testing.T) {
+	v := &vault{mountPath: "secret"}
+
+	name, err := v.ParseRef("vault://secret/my-secret")
+	if err != nil {
+		t.Fatalf("ParseRef: %v", err)
+	}
+	if name != "my-secret" {
+		t.Errorf("name = %q, want %q", name, "my-secret")
+	}
+
+	if _, err := v.ParseRef("barbican://abc"); err == nil {
+		t.Error("expected an error for a ref from a different backend")
+	}
+	if _, err := v.ParseRef("vault://other-mount/my-secret"); err == nil {
+		t.Error("expected an error for a ref on a different mount")
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/keymanager/v1/secrets"
+)
+
+// BlockStorageEncryptionOpts is the [BlockStorage.Encryption] cloud-config
+// section controlling the parameters CreateSecret uses when provisioning
+// Barbican secrets for the cinder/manila encryption-at-rest flow. Fields left
+// unset keep CreateSecret's historical aes/cbc/256/opaque defaults.
+type BlockStorageEncryptionOpts struct {
+	KeyAlgorithm      string        `gcfg:"key-algorithm"`
+	KeyMode           string        `gcfg:"key-mode"`
+	KeyBitLength      int           `gcfg:"key-bit-length"`
+	SecretType        string        `gcfg:"secret-type"`
+	DefaultExpiration time.Duration `gcfg:"default-expiration"`
+}
+
+// ToCreateOpts converts the cloud-config encryption section into the
+// CreateOpts CreateSecret/EnsureSecret expect. DefaultExpiration, when set,
+// is applied as an expiration relative to now.
+func (o BlockStorageEncryptionOpts) ToCreateOpts(name string, payload string, payloadContentType string) CreateOpts {
+	opts := CreateOpts{
+		Name:               name,
+		Payload:            payload,
+		PayloadContentType: payloadContentType,
+		Algorithm:          o.KeyAlgorithm,
+		Mode:               o.KeyMode,
+		BitLength:          o.KeyBitLength,
+		SecretType:         secrets.SecretType(o.SecretType),
+	}
+	if o.DefaultExpiration > 0 {
+		opts.Expiration = time.Now().Add(o.DefaultExpiration)
+	}
+
+	return opts
+}
+
+// barbicanSupportedSecretTypes are the secret_type values Barbican's API has
+// accepted since its v1 API. Most Barbican deployments don't publish a
+// capabilities document to check this against dynamically, so this is the
+// fallback ValidateEncryptionOpts validates opts.SecretType against.
+var barbicanSupportedSecretTypes = []string{
+	string(secrets.OpaqueSecret),
+	string(secrets.SymmetricSecret),
+	string(secrets.PrivateSecret),
+	string(secrets.PublicSecret),
+	string(secrets.PassphraseSecret),
+	string(secrets.CertificateSecret),
+}
+
+// barbicanAlgorithmCapability describes the modes and bit lengths valid for
+// one key algorithm.
+type barbicanAlgorithmCapability struct {
+	modes      []string
+	bitLengths []int
+}
+
+// barbicanSupportedAlgorithms maps each key algorithm Barbican's built-in
+// simple_crypto and PKCS11 plugins accept to its valid modes and bit
+// lengths, including the AES-GCM/512-bit combination FIPS/CNSA deployments
+// require. Like barbicanSupportedSecretTypes, this is CPO's fallback when
+// the target deployment doesn't publish a capabilities document to check
+// against dynamically.
+var barbicanSupportedAlgorithms = map[string]barbicanAlgorithmCapability{
+	"aes": {
+		modes:      []string{"cbc", "gcm"},
+		bitLengths: []int{128, 192, 256, 512},
+	},
+}
+
+// ValidateEncryptionOpts probes the target Barbican deployment's /v1/secrets
+// and /v1/containers endpoints to confirm CPO can reach and authenticate
+// against it, then checks opts against Barbican's known-supported
+// algorithm/mode/bit-length/secret_type combinations, returning a clear
+// error at boot rather than a failed secret creation the first time a PV is
+// provisioned. It's called from the barbican keymanager.Factory with the
+// options in keymanager.BarbicanConfig.Encryption.
+// keymanager.NewKeyManagerFromCloudConfig does not parse
+// [BlockStorage.Encryption] into those options, so on that path it is the
+// defaults that get validated.
+func ValidateEncryptionOpts(ctx context.Context, client *gophercloud.ServiceClient, opts BlockStorageEncryptionOpts) error {
+	for _, url := range []string{client.ServiceURL("secrets"), client.ServiceURL("containers")} {
+		if _, err := client.Get(ctx, url, nil, &gophercloud.RequestOpts{OkCodes: []int{200}}); err != nil {
+			return fmt.Errorf("keymanager: could not reach Barbican at %s: %w", url, err)
+		}
+	}
+
+	secretType := opts.SecretType
+	if secretType == "" {
+		secretType = string(secrets.OpaqueSecret)
+	}
+	if !containsString(barbicanSupportedSecretTypes, secretType) {
+		return fmt.Errorf("keymanager: secret-type %q is not supported by Barbican (supported: %v)", secretType, barbicanSupportedSecretTypes)
+	}
+
+	algorithm := opts.KeyAlgorithm
+	if algorithm == "" {
+		algorithm = DefaultKeyAlgorithm
+	}
+	capability, ok := barbicanSupportedAlgorithms[algorithm]
+	if !ok {
+		return fmt.Errorf("keymanager: key-algorithm %q is not supported by Barbican", algorithm)
+	}
+
+	mode := opts.KeyMode
+	if mode == "" {
+		mode = DefaultKeyMode
+	}
+	if !containsString(capability.modes, mode) {
+		return fmt.Errorf("keymanager: key-mode %q is not valid for key-algorithm %q (supported: %v)", mode, algorithm, capability.modes)
+	}
+
+	bitLength := opts.KeyBitLength
+	if bitLength == 0 {
+		bitLength = DefaultKeyBitLength
+	}
+	if !containsInt(capability.bitLengths, bitLength) {
+		return fmt.Errorf("keymanager: key-bit-length %d is not valid for key-algorithm %q (supported: %v)", bitLength, algorithm, capability.bitLengths)
+	}
+
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, n int) bool {
+	for _, item := range list {
+		if item == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+)
+
+func newReachableBarbican(t *testing.T) *gophercloud.ServiceClient {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(ts.Close)
+
+	return &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       ts.URL + "/",
+	}
+}
+
+func TestValidateEncryptionOpts(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    BlockStorageEncryptionOpts
+		wantErr bool
+	}{
+		{"defaults are valid", BlockStorageEncryptionOpts{}, false},
+		{"fips gcm 512 is valid", BlockStorageEncryptionOpts{KeyAlgorithm: "aes", KeyMode: "gcm", KeyBitLength: 512}, false},
+		{"unsupported algorithm", BlockStorageEncryptionOpts{KeyAlgorithm: "blowfish"}, true},
+		{"unsupported mode for algorithm", BlockStorageEncryptionOpts{KeyAlgorithm: "aes", KeyMode: "ecb"}, true},
+		{"unsupported bit length", BlockStorageEncryptionOpts{KeyAlgorithm: "aes", KeyBitLength: 1024}, true},
+		{"unsupported secret type", BlockStorageEncryptionOpts{SecretType: "not-a-real-type"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newReachableBarbican(t)
+			err := ValidateEncryptionOpts(context.Background(), client, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateEncryptionOpts(%+v) error = %v, wantErr %v", tt.opts, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateEncryptionOptsUnreachable(t *testing.T) {
+	client := &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       "http://127.0.0.1:1/",
+	}
+
+	if err := ValidateEncryptionOpts(context.Background(), client, BlockStorageEncryptionOpts{}); err == nil {
+		t.Error("expected an error when Barbican is unreachable")
+	}
+}
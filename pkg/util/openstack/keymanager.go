@@ -18,28 +18,86 @@ package openstack
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/keymanager/v1/acls"
 	"github.com/gophercloud/gophercloud/v2/openstack/keymanager/v1/secrets"
+	"github.com/gophercloud/gophercloud/v2/pagination"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/cloud-provider-openstack/pkg/metrics"
 	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
 )
 
-// EnsureSecret creates a secret if it doesn't exist.
-func EnsureSecret(ctx context.Context, client *gophercloud.ServiceClient, name string, secretType string, payload string) (string, error) {
-	secret, err := GetSecret(ctx, client, name)
+// Defaults applied by CreateOpts.withDefaults when a caller leaves the
+// corresponding field unset, preserving the historical CreateSecret behavior.
+const (
+	DefaultKeyAlgorithm = "aes"
+	DefaultKeyMode      = "cbc"
+	DefaultKeyBitLength = 256
+)
+
+// SecretMetadata is the arbitrary key/value tag map Barbican stores alongside
+// a secret at /v1/secrets/{id}/metadata, e.g. {"k8s-pv-name": "pvc-...",
+// "csi-volume-id": "..."}. EnsureSecret and DeleteSecrets key off these tags
+// instead of matching on the (user-controlled, non-unique) secret name.
+type SecretMetadata map[string]string
+
+// CreateOpts holds the parameters used to create a new Barbican secret.
+// Fields left at their zero value fall back to DefaultKeyAlgorithm/
+// DefaultKeyMode/DefaultKeyBitLength and secrets.OpaqueSecret, matching the
+// aes/cbc/256 opaque secrets CreateSecret used to hardcode.
+type CreateOpts struct {
+	Name                   string
+	Payload                string
+	PayloadContentType     string
+	PayloadContentEncoding string
+	Algorithm              string
+	Mode                   string
+	BitLength              int
+	SecretType             secrets.SecretType
+	Expiration             time.Time
+}
+
+func (o CreateOpts) withDefaults() CreateOpts {
+	if o.Algorithm == "" {
+		o.Algorithm = DefaultKeyAlgorithm
+	}
+	if o.Mode == "" {
+		o.Mode = DefaultKeyMode
+	}
+	if o.BitLength == 0 {
+		o.BitLength = DefaultKeyBitLength
+	}
+	if o.SecretType == "" {
+		o.SecretType = secrets.OpaqueSecret
+	}
+	if o.PayloadContentEncoding == "" && o.Payload != "" {
+		o.PayloadContentEncoding = "base64"
+	}
+	return o
+}
+
+// EnsureSecret creates a secret tagged with tags if no secret carrying that
+// tag set already exists, returning the ref of the existing or newly created
+// secret. name is used only as the Barbican secret name for human
+// readability; lookups match on tags so callers aren't tripped up by two
+// PVs/volumes that happen to produce the same name.
+func EnsureSecret(ctx context.Context, client *gophercloud.ServiceClient, name string, tags SecretMetadata, opts CreateOpts) (string, error) {
+	secretRef, err := findSecretByTags(ctx, client, tags)
 	if err != nil {
 		if err == cpoerrors.ErrNotFound {
-			// Create a new one
-			return CreateSecret(ctx, client, name, secretType, payload)
+			opts.Name = name
+			return CreateSecret(ctx, client, opts, tags)
 		}
-
 		return "", err
 	}
 
-	return secret.SecretRef, nil
+	return secretRef, nil
 }
 
 // GetSecret returns the secret by name
@@ -67,26 +125,162 @@ func GetSecret(ctx context.Context, client *gophercloud.ServiceClient, name stri
 	return &allSecrets[0], nil
 }
 
-// CreateSecret creates a secret in Barbican, returns the secret url.
-func CreateSecret(ctx context.Context, client *gophercloud.ServiceClient, name string, secretType string, payload string) (string, error) {
+// GetSecretPayload fetches and decodes the payload bytes stored for a secret.
+func GetSecretPayload(ctx context.Context, client *gophercloud.ServiceClient, secretRef string) ([]byte, error) {
+	secretID, err := ParseSecretID(secretRef)
+	if err != nil {
+		return nil, err
+	}
+
+	mc := metrics.NewMetricContext("secret", "get_payload")
+	payload, err := secrets.GetPayload(ctx, client, secretID, secrets.GetPayloadOpts{}).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// ListSecrets returns every secret matching opts. filter, when non-nil, is
+// applied client-side on top of the server-side ListOpts filters.
+func ListSecrets(ctx context.Context, client *gophercloud.ServiceClient, opts secrets.ListOpts, filter func(secrets.Secret) bool) ([]secrets.Secret, error) {
+	mc := metrics.NewMetricContext("secret", "list")
+	allPages, err := secrets.List(client, opts).AllPages(ctx)
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+	allSecrets, err := secrets.ExtractSecrets(allPages)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter == nil {
+		return allSecrets, nil
+	}
+
+	filtered := make([]secrets.Secret, 0, len(allSecrets))
+	for _, s := range allSecrets {
+		if filter(s) {
+			filtered = append(filtered, s)
+		}
+	}
+
+	return filtered, nil
+}
+
+// CreateSecret creates a secret in Barbican using opts, tags it with tags via
+// the metadata sub-API when tags is non-empty, and returns the secret url.
+func CreateSecret(ctx context.Context, client *gophercloud.ServiceClient, opts CreateOpts, tags SecretMetadata) (string, error) {
+	opts = opts.withDefaults()
 	createOpts := secrets.CreateOpts{
-		Name:                   name,
-		Algorithm:              "aes",
-		Mode:                   "cbc",
-		BitLength:              256,
-		PayloadContentType:     secretType,
-		PayloadContentEncoding: "base64",
-		Payload:                payload,
-		SecretType:             secrets.OpaqueSecret,
+		Name:                   opts.Name,
+		Algorithm:              opts.Algorithm,
+		Mode:                   opts.Mode,
+		BitLength:              opts.BitLength,
+		PayloadContentType:     opts.PayloadContentType,
+		PayloadContentEncoding: opts.PayloadContentEncoding,
+		Payload:                opts.Payload,
+		SecretType:             opts.SecretType,
+		Expiration:             opts.Expiration,
 	}
+
 	mc := metrics.NewMetricContext("secret", "create")
 	secret, err := secrets.Create(ctx, client, createOpts).Extract()
 	if mc.ObserveRequest(err) != nil {
 		return "", err
 	}
+
+	if len(tags) == 0 {
+		return secret.SecretRef, nil
+	}
+
+	secretID, err := ParseSecretID(secret.SecretRef)
+	if err != nil {
+		return secret.SecretRef, err
+	}
+	if err := SetSecretMetadata(ctx, client, secretID, tags); err != nil {
+		return secret.SecretRef, err
+	}
+
 	return secret.SecretRef, nil
 }
 
+// UpdateSecret attaches payload to a secret that was created without one,
+// Barbican's two-step secret creation flow.
+func UpdateSecret(ctx context.Context, client *gophercloud.ServiceClient, secretRef string, payload string, contentType string) error {
+	secretID, err := ParseSecretID(secretRef)
+	if err != nil {
+		return err
+	}
+
+	updateOpts := secrets.UpdateOpts{
+		ContentType: contentType,
+		Payload:     payload,
+	}
+	mc := metrics.NewMetricContext("secret", "update")
+	err = secrets.Update(ctx, client, secretID, updateOpts).ExtractErr()
+	return mc.ObserveRequest(err)
+}
+
+// secretMetadataURL returns the Barbican /v1/secrets/{id}/metadata URL for a
+// secret. Gophercloud's keymanager/v1/secrets package doesn't wrap this
+// sub-resource, so metadata calls go through the service client directly.
+func secretMetadataURL(client *gophercloud.ServiceClient, secretID string) string {
+	return client.ServiceURL("secrets", secretID, "metadata")
+}
+
+// GetSecretMetadata fetches the metadata map stored for a secret.
+func GetSecretMetadata(ctx context.Context, client *gophercloud.ServiceClient, secretID string) (SecretMetadata, error) {
+	var result struct {
+		Metadata SecretMetadata `json:"metadata"`
+	}
+
+	mc := metrics.NewMetricContext("secret_metadata", "get")
+	_, err := client.Get(ctx, secretMetadataURL(client, secretID), &result, nil)
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	return result.Metadata, nil
+}
+
+// SetSecretMetadata replaces the metadata map stored for a secret.
+func SetSecretMetadata(ctx context.Context, client *gophercloud.ServiceClient, secretID string, metadata SecretMetadata) error {
+	body := map[string]interface{}{"metadata": metadata}
+	mc := metrics.NewMetricContext("secret_metadata", "update")
+	_, err := client.Put(ctx, secretMetadataURL(client, secretID), body, nil, &gophercloud.RequestOpts{
+		OkCodes: []int{200, 201},
+	})
+	return mc.ObserveRequest(err)
+}
+
+// SetSecretACL grants the listed project users read access to a secret's
+// payload, so it can be shared between e.g. the cinder provisioner and the
+// CSI driver without making it world-readable within the project.
+func SetSecretACL(ctx context.Context, client *gophercloud.ServiceClient, secretID string, users []string) error {
+	setOpts := acls.SetOpts{
+		acls.SetOpt{
+			Type:  "read",
+			Users: &users,
+		},
+	}
+
+	mc := metrics.NewMetricContext("secret_acl", "update")
+	_, err := acls.SetSecretACL(ctx, client, secretID, setOpts).Extract()
+	return mc.ObserveRequest(err)
+}
+
+// GetSecretACL returns the ACL entries currently set on a secret.
+func GetSecretACL(ctx context.Context, client *gophercloud.ServiceClient, secretID string) (*acls.ACL, error) {
+	mc := metrics.NewMetricContext("secret_acl", "get")
+	acl, err := acls.GetSecretACL(ctx, client, secretID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	return acl, nil
+}
+
 // ParseSecretID return secret ID from secretRef
 func ParseSecretID(ref string) (string, error) {
 	parts := strings.Split(ref, "/")
@@ -97,34 +291,194 @@ func ParseSecretID(ref string) (string, error) {
 	return parts[len(parts)-1], nil
 }
 
-// DeleteSecrets deletes all the secrets that including the name string.
-func DeleteSecrets(ctx context.Context, client *gophercloud.ServiceClient, partName string) error {
-	listOpts := secrets.ListOpts{
-		SecretType: secrets.OpaqueSecret,
+// errSecretFound is an internal sentinel EachSecret's handler returns to stop
+// pagination as soon as findSecretByTags has a match, instead of always
+// walking to the end of the tenant's secret list.
+var errSecretFound = errors.New("keymanager: matching secret found")
+
+// findSecretByTags streams opaque secrets a page at a time via EachSecret and
+// returns the ref of the first one whose metadata contains every key/value
+// pair in tags, stopping as soon as a match is found. tags must be non-empty:
+// without at least one tag to match on, "found a secret" is indistinguishable
+// from "found the first opaque secret in the whole tenant," which would let
+// an EnsureSecret call made with no tags silently hand back an unrelated
+// secret's payload.
+//
+// Barbican's metadata API (/v1/secrets/{id}/metadata) isn't queryable
+// server-side, so even with EachSecret's early exit this is still one
+// metadata GET per secret scanned in the worst case (no match, or a match
+// near the end of the list) — there is no way to turn this into a true
+// server-side lookup against the current Barbican API.
+func findSecretByTags(ctx context.Context, client *gophercloud.ServiceClient, tags SecretMetadata) (string, error) {
+	if len(tags) == 0 {
+		return "", fmt.Errorf("keymanager: refusing to look up a secret with no tags to match on")
 	}
-	mc := metrics.NewMetricContext("secret", "list")
-	allPages, err := secrets.List(client, listOpts).AllPages(ctx)
-	if mc.ObserveRequest(err) != nil {
-		return err
+
+	var found string
+	err := EachSecret(ctx, client, secrets.ListOpts{SecretType: secrets.OpaqueSecret}, func(s *secrets.Secret) error {
+		secretID, err := ParseSecretID(s.SecretRef)
+		if err != nil {
+			return err
+		}
+		metadata, err := GetSecretMetadata(ctx, client, secretID)
+		if err != nil {
+			return err
+		}
+		if metadataMatches(metadata, tags) {
+			found = s.SecretRef
+			return errSecretFound
+		}
+		return nil
+	})
+	if err != nil && err != errSecretFound {
+		return "", err
 	}
-	allSecrets, err := secrets.ExtractSecrets(allPages)
-	if err != nil {
-		return err
+	if found == "" {
+		return "", cpoerrors.ErrNotFound
 	}
 
-	for _, s := range allSecrets {
-		if strings.Contains(s.Name, partName) {
+	return found, nil
+}
+
+func metadataMatches(metadata, tags SecretMetadata) bool {
+	for k, v := range tags {
+		if metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ListSecretsFiltered lists secrets using Barbican's server-side query
+// parameters (opts.Name, Alg, Mode, Bits, SecretType, CreatedQuery,
+// UpdatedQuery, ExpirationQuery, Sort) instead of pulling every secret in the
+// project and filtering client-side.
+func ListSecretsFiltered(ctx context.Context, client *gophercloud.ServiceClient, opts secrets.ListOpts) ([]secrets.Secret, error) {
+	return ListSecrets(ctx, client, opts, nil)
+}
+
+// EachSecret invokes handler once per secret matching opts, a page at a time,
+// instead of buffering the whole result set in memory the way ListSecrets and
+// ListSecretsFiltered do. An error returned from handler stops iteration and
+// is returned to the caller.
+func EachSecret(ctx context.Context, client *gophercloud.ServiceClient, opts secrets.ListOpts, handler func(*secrets.Secret) error) error {
+	mc := metrics.NewMetricContext("secret", "list")
+	err := secrets.List(client, opts).EachPage(ctx, func(_ context.Context, page pagination.Page) (bool, error) {
+		pageSecrets, err := secrets.ExtractSecrets(page)
+		if err != nil {
+			return false, err
+		}
+		for i := range pageSecrets {
+			if err := handler(&pageSecrets[i]); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	return mc.ObserveRequest(err)
+}
+
+// DefaultDeleteConcurrency is the number of concurrent delete workers
+// DeleteSecrets uses when DeleteOpts.Concurrency is left unset.
+const DefaultDeleteConcurrency = 8
+
+// DeleteOpts configures DeleteSecrets.
+type DeleteOpts struct {
+	// Concurrency bounds how many deletes are in flight at once. Defaults to
+	// DefaultDeleteConcurrency when <= 0.
+	Concurrency int
+	// DryRun, when true, matches secrets but deletes nothing.
+	DryRun bool
+}
+
+// DeleteSecrets deletes every opaque secret whose metadata has tagKey set to
+// tagValue. It streams through Barbican's paginated secret list via
+// EachSecret rather than buffering the whole tenant's secrets in memory, and
+// fans matches out across opts.Concurrency delete workers. It always returns
+// the refs that matched, so callers can pass DeleteOpts{DryRun: true} to see
+// what would be deleted without deleting it.
+func DeleteSecrets(ctx context.Context, client *gophercloud.ServiceClient, tagKey string, tagValue string, opts DeleteOpts) ([]string, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultDeleteConcurrency
+	}
+
+	var (
+		matchedMu sync.Mutex
+		matched   []string
+	)
+
+	toDelete := make(chan string)
+	listErrCh := make(chan error, 1)
+	go func() {
+		defer close(toDelete)
+		listErrCh <- EachSecret(ctx, client, secrets.ListOpts{SecretType: secrets.OpaqueSecret}, func(s *secrets.Secret) error {
 			secretID, err := ParseSecretID(s.SecretRef)
 			if err != nil {
 				return err
 			}
-			mc := metrics.NewMetricContext("secret", "delete")
-			err = secrets.Delete(ctx, client, secretID).ExtractErr()
-			if mc.ObserveRequest(err) != nil && !cpoerrors.IsNotFound(err) {
+			metadata, err := GetSecretMetadata(ctx, client, secretID)
+			if err != nil {
 				return err
 			}
-		}
+			if metadata[tagKey] != tagValue {
+				return nil
+			}
+
+			matchedMu.Lock()
+			matched = append(matched, s.SecretRef)
+			matchedMu.Unlock()
+
+			if opts.DryRun {
+				return nil
+			}
+
+			select {
+			case toDelete <- s.SecretRef:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	var (
+		errsMu     sync.Mutex
+		aggregated []error
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ref := range toDelete {
+				secretID, err := ParseSecretID(ref)
+				if err != nil {
+					errsMu.Lock()
+					aggregated = append(aggregated, err)
+					errsMu.Unlock()
+					continue
+				}
+
+				mc := metrics.NewMetricContext("secret", "delete")
+				err = secrets.Delete(ctx, client, secretID).ExtractErr()
+				if mc.ObserveRequest(err) != nil && !cpoerrors.IsNotFound(err) {
+					errsMu.Lock()
+					aggregated = append(aggregated, err)
+					errsMu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := <-listErrCh; err != nil {
+		aggregated = append(aggregated, err)
+	}
+	if len(aggregated) > 0 {
+		return matched, utilerrors.NewAggregate(aggregated)
 	}
 
-	return nil
+	return matched, nil
 }
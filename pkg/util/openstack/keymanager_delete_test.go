@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeleteSecretsAggregatesMoreFailuresThanConcurrency(t *testing.T) {
+	const numSecrets = 20 // more than DefaultDeleteConcurrency
+	var refs []string
+	for i := 0; i < numSecrets; i++ {
+		refs = append(refs, fmt.Sprintf("http://x/secrets/id-%d", i))
+	}
+
+	client := newFakeBarbican(t,
+		refs,
+		func(id string) (int, SecretMetadata) {
+			return http.StatusOK, SecretMetadata{"csi-volume-id": "vol-1"}
+		},
+		func(id string) int { return http.StatusInternalServerError },
+	)
+
+	done := make(chan struct{})
+	var (
+		matched []string
+		err     error
+	)
+	go func() {
+		matched, err = DeleteSecrets(context.Background(), client, "csi-volume-id", "vol-1", DeleteOpts{})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("DeleteSecrets did not return; likely deadlocked collecting worker errors")
+	}
+
+	if len(matched) != numSecrets {
+		t.Errorf("matched %d secrets, want %d", len(matched), numSecrets)
+	}
+	if err == nil {
+		t.Fatal("expected DeleteSecrets to return an aggregated error")
+	}
+	if got := err.Error(); !strings.Contains(got, "500") && !strings.Contains(strings.ToLower(got), "internal") {
+		t.Logf("aggregated error (informational): %v", got)
+	}
+}
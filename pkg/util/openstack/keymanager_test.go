@@ -0,0 +1,220 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/keymanager/v1/secrets"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+)
+
+func TestMetadataMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata SecretMetadata
+		tags     SecretMetadata
+		want     bool
+	}{
+		// metadataMatches itself is a plain subset check and empty tags are
+		// vacuously a subset of anything; findSecretByTags is what refuses to
+		// call this with an empty tag set (see TestFindSecretByTagsRequiresTags)
+		// so "matches everything" never actually reaches a caller.
+		{"empty tags always match", SecretMetadata{"a": "1"}, SecretMetadata{}, true},
+		{"exact match", SecretMetadata{"a": "1", "b": "2"}, SecretMetadata{"a": "1"}, true},
+		{"mismatched value", SecretMetadata{"a": "1"}, SecretMetadata{"a": "2"}, false},
+		{"missing key", SecretMetadata{"a": "1"}, SecretMetadata{"b": "1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := metadataMatches(tt.metadata, tt.tags); got != tt.want {
+				t.Errorf("metadataMatches(%v, %v) = %v, want %v", tt.metadata, tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateOptsWithDefaults(t *testing.T) {
+	got := CreateOpts{Payload: "cGF5bG9hZA=="}.withDefaults()
+
+	if got.Algorithm != DefaultKeyAlgorithm {
+		t.Errorf("Algorithm = %q, want %q", got.Algorithm, DefaultKeyAlgorithm)
+	}
+	if got.Mode != DefaultKeyMode {
+		t.Errorf("Mode = %q, want %q", got.Mode, DefaultKeyMode)
+	}
+	if got.BitLength != DefaultKeyBitLength {
+		t.Errorf("BitLength = %d, want %d", got.BitLength, DefaultKeyBitLength)
+	}
+	if got.SecretType != secrets.OpaqueSecret {
+		t.Errorf("SecretType = %q, want %q", got.SecretType, secrets.OpaqueSecret)
+	}
+	if got.PayloadContentEncoding != "base64" {
+		t.Errorf("PayloadContentEncoding = %q, want base64", got.PayloadContentEncoding)
+	}
+
+	explicit := CreateOpts{
+		Algorithm:  "aes",
+		Mode:       "gcm",
+		BitLength:  512,
+		SecretType: secrets.SymmetricSecret,
+	}.withDefaults()
+	if explicit.Mode != "gcm" || explicit.BitLength != 512 || explicit.SecretType != secrets.SymmetricSecret {
+		t.Errorf("withDefaults overrode explicitly set fields: %+v", explicit)
+	}
+}
+
+func TestParseSecretID(t *testing.T) {
+	id, err := ParseSecretID("https://barbican/v1/secrets/abc-123")
+	if err != nil {
+		t.Fatalf("ParseSecretID: %v", err)
+	}
+	if id != "abc-123" {
+		t.Errorf("ParseSecretID = %q, want abc-123", id)
+	}
+
+	if _, err := ParseSecretID("not-a-url"); err == nil {
+		t.Error("expected an error for a ref with no path separators")
+	}
+}
+
+// newFakeBarbican starts a test server that serves a single page of secrets
+// and lets the caller control the metadata GET and DELETE responses per
+// secret ID.
+func newFakeBarbican(t *testing.T, refs []string, metadataHandler func(id string) (int, SecretMetadata), deleteHandler func(id string) int) *gophercloud.ServiceClient {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/secrets", func(w http.ResponseWriter, r *http.Request) {
+		type secretJSON struct {
+			SecretRef string `json:"secret_ref"`
+		}
+		body := struct {
+			Secrets []secretJSON `json:"secrets"`
+		}{}
+		for _, ref := range refs {
+			body.Secrets = append(body.Secrets, secretJSON{SecretRef: ref})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	})
+	mux.HandleFunc("/secrets/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 3 || parts[2] != "metadata" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		id := parts[1]
+
+		switch r.Method {
+		case http.MethodGet:
+			code, metadata := metadataHandler(id)
+			w.WriteHeader(code)
+			if code == http.StatusOK {
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(struct {
+					Metadata SecretMetadata `json:"metadata"`
+				}{metadata})
+			}
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	// secrets.Delete targets /secrets/{id} directly (no trailing segment),
+	// which the "/secrets/" pattern above also matches; special-case it here.
+	base := mux
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trimmed := strings.TrimPrefix(r.URL.Path, "/secrets/")
+		if r.Method == http.MethodDelete && !strings.Contains(trimmed, "/") {
+			w.WriteHeader(deleteHandler(trimmed))
+			return
+		}
+		base.ServeHTTP(w, r)
+	})
+
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	return &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       ts.URL + "/",
+	}
+}
+
+func TestFindSecretByTagsPropagatesMetadataError(t *testing.T) {
+	client := newFakeBarbican(t,
+		[]string{"http://x/secrets/good", "http://x/secrets/bad"},
+		func(id string) (int, SecretMetadata) {
+			if id == "bad" {
+				return http.StatusInternalServerError, nil
+			}
+			return http.StatusOK, SecretMetadata{"k8s-pv-name": "other-pv"}
+		},
+		func(id string) int { return http.StatusNoContent },
+	)
+
+	_, err := findSecretByTags(context.Background(), client, SecretMetadata{"k8s-pv-name": "pvc-1"})
+	if err == nil {
+		t.Fatal("expected the metadata lookup error to propagate, got nil")
+	}
+	if err == cpoerrors.ErrNotFound {
+		t.Fatalf("got ErrNotFound instead of the underlying transport error: %v", err)
+	}
+}
+
+func TestFindSecretByTagsRequiresTags(t *testing.T) {
+	client := newFakeBarbican(t,
+		[]string{"http://x/secrets/first"},
+		func(id string) (int, SecretMetadata) { return http.StatusOK, SecretMetadata{"k8s-pv-name": "unrelated-pv"} },
+		func(id string) int { return http.StatusNoContent },
+	)
+
+	if _, err := findSecretByTags(context.Background(), client, SecretMetadata{}); err == nil {
+		t.Fatal("expected findSecretByTags to reject an empty tag set instead of returning the first secret in the tenant")
+	}
+}
+
+func TestFindSecretByTagsStopsAtFirstMatch(t *testing.T) {
+	client := newFakeBarbican(t,
+		[]string{"http://x/secrets/first", "http://x/secrets/second"},
+		func(id string) (int, SecretMetadata) {
+			if id == "first" {
+				return http.StatusOK, SecretMetadata{"k8s-pv-name": "pvc-1"}
+			}
+			// If findSecretByTags didn't stop at the first match, it would hit
+			// this handler and fail the lookup.
+			return http.StatusInternalServerError, nil
+		},
+		func(id string) int { return http.StatusNoContent },
+	)
+
+	ref, err := findSecretByTags(context.Background(), client, SecretMetadata{"k8s-pv-name": "pvc-1"})
+	if err != nil {
+		t.Fatalf("findSecretByTags: %v", err)
+	}
+	if ref != "http://x/secrets/first" {
+		t.Errorf("ref = %q, want the first matching secret", ref)
+	}
+}